@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// AppConfig is the top-level configuration file format. It holds one or
+// more provider configurations so a single run can update several DNS
+// records, possibly with different providers, in one go.
+type AppConfig struct {
+	Providers []ProviderConfig `json:"providers"`
+
+	// IPVersion selects which address families to discover and push:
+	// "ipv4" (default), "ipv6", or "both".
+	IPVersion string `json:"ip_version"`
+
+	// CheckIPv4Url / CheckIPv6Url override the default ipify endpoints
+	// used to discover the current public IP for each family.
+	CheckIPv4Url string `json:"url_check_ipv4"`
+	CheckIPv6Url string `json:"url_check_ipv6"`
+
+	// Interval is how often daemon mode polls for IP changes (e.g. "5m").
+	// ForceInterval is the longest daemon mode will go without pushing an
+	// update even if the IP hasn't changed, so provider accounts that
+	// expire from inactivity stay alive (e.g. "24h").
+	Interval      string `json:"interval"`
+	ForceInterval string `json:"force_interval"`
+
+	// IPSources lists the backends used to discover the current public
+	// IP, tried in order until one succeeds. When empty, CheckIPv4Url /
+	// CheckIPv6Url are used directly (the original http-only behavior).
+	IPSources []IPSourceConfig `json:"ip_source"`
+
+	// AllowedCIDRs / DeniedCIDRs sanity-check a freshly fetched IP before
+	// it's sent to any provider. A fetched IP in a denied range, or in a
+	// private/loopback/link-local/multicast/unspecified range that isn't
+	// covered by AllowedCIDRs, is rejected.
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+	DeniedCIDRs  []string `json:"denied_cidrs"`
+
+	// ListenAddr, when set, starts the /healthz, /metrics and /status
+	// HTTP server in daemon mode (e.g. ":8080"). Disabled by default.
+	ListenAddr string `json:"listen_addr"`
+
+	// Webhooks is a list of Shoutrrr-style notification URLs
+	// (discord://, slack://, generic://, or a plain http(s):// JSON
+	// endpoint) notified on IP change or update failure.
+	Webhooks []string `json:"webhooks"`
+}
+
+// IPSourceConfig describes a single IP discovery backend. The `Type`
+// field selects the backend; the remaining fields are interpreted by
+// that backend's constructor.
+type IPSourceConfig struct {
+	Type string `json:"type"`
+
+	// http
+	URLs []string `json:"urls"`
+
+	// interface
+	Interface string `json:"interface"`
+	CIDR      string `json:"cidr"`
+
+	// stun
+	Server string `json:"server"`
+}
+
+// ProviderConfig describes a single provider entry. The `Type` field
+// (JSON key "provider") selects which backend implementation handles the
+// entry; the remaining fields are a superset of what any backend might
+// need and are interpreted by that backend's constructor.
+type ProviderConfig struct {
+	Type     string `json:"provider"`
+	Hostname string `json:"hostname"`
+
+	// credential style fields, not all used by every provider
+	Token    string `json:"token"`
+	Username string `json:"user"`
+	Password string `json:"pass"`
+
+	// provider-specific addressing
+	Zone       string `json:"zone"`
+	RecordID   string `json:"record_id"`
+	RecordIDv6 string `json:"record_id_v6"`
+
+	// only used by the legacy/generic dyndns-style provider
+	CheckIPUrl  string `json:"url_check_ip"`
+	UpdateIPUrl string `json:"url_update_ip"`
+}
+
+// getConfig reads the configuration file at fpath and decodes it into an
+// AppConfig with one entry per configured provider.
+func getConfig(fpath string) (AppConfig, error) {
+
+	var config AppConfig
+
+	if _, err := os.Stat(fpath); err != nil {
+		return config, err
+	}
+
+	fconfig, err := os.Open(fpath)
+	if err != nil {
+		return config, err
+	}
+	defer fconfig.Close()
+
+	parser := json.NewDecoder(fconfig)
+	if err = parser.Decode(&config); err != nil {
+		return config, err
+	}
+
+	if len(config.Providers) == 0 {
+		return config, fmt.Errorf("getConfig: no providers configured")
+	}
+
+	return config, nil
+}
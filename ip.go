@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Record types a provider can be asked to update.
+const (
+	RecordTypeA    = "A"
+	RecordTypeAAAA = "AAAA"
+)
+
+const (
+	defaultCheckIPv4Url = "https://api.ipify.org"
+	defaultCheckIPv6Url = "https://api6.ipify.org"
+)
+
+// fetchIPs resolves the current public IP address(es) according to the
+// app's ip_version setting, returning a map keyed by record type
+// (RecordTypeA / RecordTypeAAAA) ready to hand to a Provider.
+//
+// When ip_source backends are configured, they're tried in order and the
+// first one to succeed determines the address; otherwise CheckIPv4Url /
+// CheckIPv6Url are fetched directly over HTTP, as before.
+func fetchIPs(ctx context.Context, config AppConfig) (map[string]string, error) {
+	policy, err := newIPPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips map[string]string
+	if len(config.IPSources) > 0 {
+		ips, err = fetchIPsFromSources(ctx, config)
+	} else {
+		ips, err = fetchIPsFromHTTP(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range ips {
+		if err := policy.validate(ip); err != nil {
+			return nil, err
+		}
+	}
+	return ips, nil
+}
+
+// recordTypesForVersion maps an ip_version setting to the record types
+// that need an address, the same split fetchIPsFromHTTP uses.
+func recordTypesForVersion(ipVersion string) []string {
+	switch ipVersion {
+	case "ipv6":
+		return []string{RecordTypeAAAA}
+	case "both":
+		return []string{RecordTypeA, RecordTypeAAAA}
+	default: // "ipv4" or unset
+		return []string{RecordTypeA}
+	}
+}
+
+// fetchIPsFromSources fetches the configured ip_source backends, honoring
+// ip_version the same way fetchIPsFromHTTP does: each wanted record type
+// is resolved independently by trying the sources in order until one
+// returns an address of the matching family.
+func fetchIPsFromSources(ctx context.Context, config AppConfig) (map[string]string, error) {
+	sources := make([]IPSource, 0, len(config.IPSources))
+	for _, cfg := range config.IPSources {
+		source, err := NewIPSource(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+
+	ips := make(map[string]string)
+	for _, recordType := range recordTypesForVersion(config.IPVersion) {
+		addr, err := fetchFromSources(ctx, sources, recordType)
+		if err != nil {
+			return nil, err
+		}
+		ips[recordType] = addr.String()
+	}
+	return ips, nil
+}
+
+// fetchIPsFromHTTP is the original behavior: fetch straight from a
+// check-IP URL, picked by ip_version.
+func fetchIPsFromHTTP(config AppConfig) (map[string]string, error) {
+	ips := make(map[string]string)
+
+	v4Url := config.CheckIPv4Url
+	if v4Url == "" {
+		v4Url = defaultCheckIPv4Url
+	}
+	v6Url := config.CheckIPv6Url
+	if v6Url == "" {
+		v6Url = defaultCheckIPv6Url
+	}
+
+	switch config.IPVersion {
+	case "ipv6":
+		ip, err := fetchIP(v6Url)
+		if err != nil {
+			return nil, err
+		}
+		ips[RecordTypeAAAA] = ip
+	case "both":
+		ipv4, err := fetchIP(v4Url)
+		if err != nil {
+			return nil, err
+		}
+		ipv6, err := fetchIP(v6Url)
+		if err != nil {
+			return nil, err
+		}
+		ips[RecordTypeA] = ipv4
+		ips[RecordTypeAAAA] = ipv6
+	default: // "ipv4" or unset
+		ip, err := fetchIP(v4Url)
+		if err != nil {
+			return nil, err
+		}
+		ips[RecordTypeA] = ip
+	}
+
+	return ips, nil
+}
+
+// primaryIP picks a single representative address out of a record-type-
+// keyed ip map, for call sites (webhook messages) that only want one
+// address to display. It prefers the A record, falling back to AAAA so
+// ipv6-only configurations still get a sensible value instead of "".
+func primaryIP(ips map[string]string) string {
+	if ip, ok := ips[RecordTypeA]; ok {
+		return ip
+	}
+	return ips[RecordTypeAAAA]
+}
+
+// fetchIP fetches and validates a single IP address (v4 or v6, whichever
+// the check-IP service returns) from checkIPUrl.
+func fetchIP(checkIPUrl string) (string, error) {
+	response, err := http.Get(checkIPUrl)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("fetchIP: body parsing error %v", err)
+	}
+
+	ip := strings.TrimSpace(string(body))
+	if ip == "" {
+		return "", fmt.Errorf("fetchIP: request did not return an IP")
+	}
+
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("fetchIP: invalid or unknown IP format: %s", ip)
+	}
+
+	return ip, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// IPSource discovers the machine's current public IP address without
+// relying on a single hardcoded echo service. Fetch returns the address
+// this backend was able to discover, or an error if it couldn't.
+type IPSource interface {
+	Name() string
+	Fetch(ctx context.Context) (netip.Addr, error)
+}
+
+// ipSourceFactories maps the ip_source config "type" discriminator to a
+// constructor for that backend.
+var ipSourceFactories = map[string]func(IPSourceConfig) (IPSource, error){
+	"http":      newHTTPIPSource,
+	"interface": newInterfaceIPSource,
+	"upnp":      newUPnPIPSource,
+	"stun":      newSTUNIPSource,
+}
+
+// NewIPSource builds the concrete IPSource for a single ip_source config
+// entry based on its Type.
+func NewIPSource(cfg IPSourceConfig) (IPSource, error) {
+	factory, ok := ipSourceFactories[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("NewIPSource: unknown ip source type %q", cfg.Type)
+	}
+	return factory(cfg)
+}
+
+// fetchFromSources tries each source in order and returns the first
+// successfully discovered address of the wanted record type (RecordTypeA
+// or RecordTypeAAAA), so a down or untrusted echo service doesn't take
+// the whole run with it, and so a source that only speaks the other
+// address family (e.g. STUN returning IPv4 when AAAA was requested) is
+// skipped rather than silently accepted.
+func fetchFromSources(ctx context.Context, sources []IPSource, wantRecordType string) (netip.Addr, error) {
+	var lastErr error
+	for _, source := range sources {
+		addr, err := source.Fetch(ctx)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %v", source.Name(), err)
+			continue
+		}
+		if recordTypeOf(addr) != wantRecordType {
+			lastErr = fmt.Errorf("%s: returned a %s address, wanted %s", source.Name(), recordTypeOf(addr), wantRecordType)
+			continue
+		}
+		return addr, nil
+	}
+	return netip.Addr{}, fmt.Errorf("fetchFromSources: all ip sources failed, last error: %v", lastErr)
+}
+
+// recordTypeOf reports whether addr is an A (IPv4) or AAAA (IPv6)
+// address.
+func recordTypeOf(addr netip.Addr) string {
+	if addr.Is4() || addr.Is4In6() {
+		return RecordTypeA
+	}
+	return RecordTypeAAAA
+}
+
+// -- http: the original behavior, a list of check-IP URLs tried in order --
+
+type httpIPSource struct {
+	urls []string
+}
+
+func newHTTPIPSource(cfg IPSourceConfig) (IPSource, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, fmt.Errorf("http ip source: urls is required")
+	}
+	return &httpIPSource{urls: cfg.URLs}, nil
+}
+
+func (s *httpIPSource) Name() string { return "http" }
+
+func (s *httpIPSource) Fetch(ctx context.Context) (netip.Addr, error) {
+	var lastErr error
+	for _, u := range s.urls {
+		ip, err := fetchIP(u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return addr, nil
+	}
+	return netip.Addr{}, fmt.Errorf("http ip source: all urls failed, last error: %v", lastErr)
+}
+
+// -- interface: read the address straight off a local network interface --
+
+type interfaceIPSource struct {
+	name string
+	cidr netip.Prefix
+}
+
+func newInterfaceIPSource(cfg IPSourceConfig) (IPSource, error) {
+	if cfg.Interface == "" {
+		return nil, fmt.Errorf("interface ip source: interface is required")
+	}
+
+	source := &interfaceIPSource{name: cfg.Interface}
+	if cfg.CIDR != "" {
+		prefix, err := netip.ParsePrefix(cfg.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("interface ip source: invalid cidr %q: %v", cfg.CIDR, err)
+		}
+		source.cidr = prefix
+	}
+	return source, nil
+}
+
+func (s *interfaceIPSource) Name() string { return "interface:" + s.name }
+
+func (s *interfaceIPSource) Fetch(ctx context.Context) (netip.Addr, error) {
+	iface, err := net.InterfaceByName(s.name)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("interface ip source: %v", err)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("interface ip source: %v", err)
+	}
+
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		addr, ok := netip.AddrFromSlice(ipNet.IP)
+		if !ok {
+			continue
+		}
+		addr = addr.Unmap()
+
+		if !addr.IsGlobalUnicast() || addr.IsLinkLocalUnicast() {
+			continue
+		}
+		if s.cidr.IsValid() && !s.cidr.Contains(addr) {
+			continue
+		}
+		return addr, nil
+	}
+
+	return netip.Addr{}, fmt.Errorf("interface ip source: no usable global unicast address found on %s", s.name)
+}
+
+// -- stun: ask a public STUN server what address it sees us connect from --
+
+const (
+	stunBindingRequest    = 0x0001
+	stunMagicCookie       = 0x2112A442
+	stunAttrXORMappedAddr = 0x0020
+	stunAttrMappedAddr    = 0x0001
+	stunIPv4Family        = 0x01
+	stunIPv6Family        = 0x02
+	defaultSTUNServer     = "stun.l.google.com:19302"
+)
+
+type stunIPSource struct {
+	server string
+}
+
+func newSTUNIPSource(cfg IPSourceConfig) (IPSource, error) {
+	server := cfg.Server
+	if server == "" {
+		server = defaultSTUNServer
+	}
+	return &stunIPSource{server: server}, nil
+}
+
+func (s *stunIPSource) Name() string { return "stun:" + s.server }
+
+func (s *stunIPSource) Fetch(ctx context.Context) (netip.Addr, error) {
+	conn, err := net.Dial("udp", s.server)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("stun: dial %s: %v", s.server, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+
+	var transactionID [12]byte
+	rand.Read(transactionID[:])
+
+	request := make([]byte, 20)
+	binary.BigEndian.PutUint16(request[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint16(request[2:4], 0) // message length, no attributes
+	binary.BigEndian.PutUint32(request[4:8], stunMagicCookie)
+	copy(request[8:20], transactionID[:])
+
+	if _, err := conn.Write(request); err != nil {
+		return netip.Addr{}, fmt.Errorf("stun: write request: %v", err)
+	}
+
+	response := make([]byte, 512)
+	n, err := conn.Read(response)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("stun: read response: %v", err)
+	}
+
+	return parseSTUNResponse(response[:n], transactionID)
+}
+
+// parseSTUNResponse extracts the public address from a STUN Binding
+// Response, preferring XOR-MAPPED-ADDRESS over the legacy MAPPED-ADDRESS.
+func parseSTUNResponse(data []byte, transactionID [12]byte) (netip.Addr, error) {
+	if len(data) < 20 {
+		return netip.Addr{}, fmt.Errorf("stun: response too short")
+	}
+
+	// msgLen is a uint16, so "20+msgLen" must be widened to int before the
+	// add: computed as uint16 it wraps for any msgLen > 65515, defeating
+	// the bounds check below and turning a malformed/malicious response
+	// into an out-of-range slice panic instead of a clean error.
+	msgLen := int(binary.BigEndian.Uint16(data[2:4]))
+	if 20+msgLen > len(data) {
+		return netip.Addr{}, fmt.Errorf("stun: truncated response")
+	}
+
+	attrs := data[20 : 20+msgLen]
+	var mapped, xorMapped []byte
+
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := binary.BigEndian.Uint16(attrs[2:4])
+		if int(4+attrLen) > len(attrs) {
+			break
+		}
+		value := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunAttrXORMappedAddr:
+			xorMapped = value
+		case stunAttrMappedAddr:
+			mapped = value
+		}
+
+		// attributes are padded to a 4-byte boundary
+		advance := 4 + int(attrLen)
+		if pad := advance % 4; pad != 0 {
+			advance += 4 - pad
+		}
+		attrs = attrs[advance:]
+	}
+
+	if xorMapped != nil {
+		return decodeSTUNAddress(xorMapped, transactionID)
+	}
+	if mapped != nil {
+		return decodeSTUNAddress(mapped, [12]byte{})
+	}
+	return netip.Addr{}, fmt.Errorf("stun: response had no mapped address attribute")
+}
+
+// decodeSTUNAddress decodes a (XOR-)MAPPED-ADDRESS attribute value. When
+// xorKey is non-zero it un-XORs the address per RFC 5389 section 15.2.
+func decodeSTUNAddress(value []byte, xorKey [12]byte) (netip.Addr, error) {
+	if len(value) < 4 {
+		return netip.Addr{}, fmt.Errorf("stun: malformed address attribute")
+	}
+	family := value[1]
+
+	switch family {
+	case stunIPv4Family:
+		if len(value) < 8 {
+			return netip.Addr{}, fmt.Errorf("stun: malformed ipv4 address attribute")
+		}
+		var ipBytes [4]byte
+		copy(ipBytes[:], value[4:8])
+		if xorKey != ([12]byte{}) {
+			cookie := make([]byte, 4)
+			binary.BigEndian.PutUint32(cookie, stunMagicCookie)
+			for i := range ipBytes {
+				ipBytes[i] ^= cookie[i]
+			}
+		}
+		return netip.AddrFrom4(ipBytes), nil
+	case stunIPv6Family:
+		if len(value) < 20 {
+			return netip.Addr{}, fmt.Errorf("stun: malformed ipv6 address attribute")
+		}
+		var ipBytes [16]byte
+		copy(ipBytes[:], value[4:20])
+		if xorKey != ([12]byte{}) {
+			cookie := make([]byte, 4)
+			binary.BigEndian.PutUint32(cookie, stunMagicCookie)
+			xorSource := append(cookie, xorKey[:]...)
+			for i := range ipBytes {
+				ipBytes[i] ^= xorSource[i]
+			}
+		}
+		return netip.AddrFrom16(ipBytes), nil
+	default:
+		return netip.Addr{}, fmt.Errorf("stun: unknown address family %d", family)
+	}
+}
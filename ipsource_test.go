@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+)
+
+// buildSTUNAttr encodes a single STUN attribute (type + length + value,
+// padded to a 4-byte boundary per RFC 5389 section 15).
+func buildSTUNAttr(attrType uint16, value []byte) []byte {
+	padded := len(value)
+	if rem := padded % 4; rem != 0 {
+		padded += 4 - rem
+	}
+	buf := make([]byte, 4+padded)
+	binary.BigEndian.PutUint16(buf[0:2], attrType)
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(value)))
+	copy(buf[4:], value)
+	return buf
+}
+
+// buildSTUNResponse wraps attrs in a minimal Binding Success Response
+// header (message type and magic cookie aren't inspected by
+// parseSTUNResponse beyond the length field).
+func buildSTUNResponse(transactionID [12]byte, attrs []byte) []byte {
+	buf := make([]byte, 20+len(attrs))
+	binary.BigEndian.PutUint16(buf[0:2], 0x0101)
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(attrs)))
+	binary.BigEndian.PutUint32(buf[4:8], stunMagicCookie)
+	copy(buf[8:20], transactionID[:])
+	copy(buf[20:], attrs)
+	return buf
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i%len(b)]
+	}
+	return out
+}
+
+func cookieBytes() []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, stunMagicCookie)
+	return b
+}
+
+func TestDecodeSTUNAddressIPv4XorMapped(t *testing.T) {
+	ip := []byte{203, 0, 113, 5}
+	xored := xorBytes(ip, cookieBytes())
+	value := append([]byte{0, stunIPv4Family, 0x00, 0x00}, xored...)
+
+	// A zero transaction ID is decodeSTUNAddress's sentinel for "don't
+	// un-XOR" (used for the legacy MAPPED-ADDRESS path), so it must be
+	// non-zero here to exercise the XOR-MAPPED-ADDRESS path.
+	var transactionID [12]byte
+	copy(transactionID[:], []byte("abcdefghijkl"))
+	addr, err := decodeSTUNAddress(value, transactionID)
+	if err != nil {
+		t.Fatalf("decodeSTUNAddress: %v", err)
+	}
+	if want := netip.AddrFrom4([4]byte{203, 0, 113, 5}); addr != want {
+		t.Errorf("got %v, want %v", addr, want)
+	}
+}
+
+func TestDecodeSTUNAddressIPv4LegacyMapped(t *testing.T) {
+	value := []byte{0, stunIPv4Family, 0x00, 0x00, 198, 51, 100, 7}
+
+	addr, err := decodeSTUNAddress(value, [12]byte{})
+	if err != nil {
+		t.Fatalf("decodeSTUNAddress: %v", err)
+	}
+	if want := netip.AddrFrom4([4]byte{198, 51, 100, 7}); addr != want {
+		t.Errorf("got %v, want %v", addr, want)
+	}
+}
+
+func TestDecodeSTUNAddressIPv6XorMapped(t *testing.T) {
+	ip := netip.MustParseAddr("2001:db8::1").As16()
+	var transactionID [12]byte
+	copy(transactionID[:], []byte("abcdefghijkl"))
+
+	xorSource := append(cookieBytes(), transactionID[:]...)
+	xored := xorBytes(ip[:], xorSource)
+	value := append([]byte{0, stunIPv6Family, 0x00, 0x00}, xored...)
+
+	addr, err := decodeSTUNAddress(value, transactionID)
+	if err != nil {
+		t.Fatalf("decodeSTUNAddress: %v", err)
+	}
+	if want := netip.AddrFrom16(ip); addr != want {
+		t.Errorf("got %v, want %v", addr, want)
+	}
+}
+
+func TestDecodeSTUNAddressErrors(t *testing.T) {
+	cases := map[string][]byte{
+		"too short":      {0, stunIPv4Family},
+		"truncated ipv4": {0, stunIPv4Family, 0, 0, 1, 2},
+		"unknown family": {0, 0xFF, 0, 0, 1, 2, 3, 4},
+	}
+	for name, value := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeSTUNAddress(value, [12]byte{}); err == nil {
+				t.Errorf("expected an error, got none")
+			}
+		})
+	}
+}
+
+func TestParseSTUNResponsePrefersXorMapped(t *testing.T) {
+	var transactionID [12]byte
+	copy(transactionID[:], []byte("abcdefghijkl"))
+
+	xoredIP := xorBytes([]byte{203, 0, 113, 5}, cookieBytes())
+	xorAttr := buildSTUNAttr(stunAttrXORMappedAddr, append([]byte{0, stunIPv4Family, 0, 0}, xoredIP...))
+	legacyAttr := buildSTUNAttr(stunAttrMappedAddr, []byte{0, stunIPv4Family, 0, 0, 9, 9, 9, 9})
+
+	attrs := append(xorAttr, legacyAttr...)
+	response := buildSTUNResponse(transactionID, attrs)
+
+	addr, err := parseSTUNResponse(response, transactionID)
+	if err != nil {
+		t.Fatalf("parseSTUNResponse: %v", err)
+	}
+	if want := netip.AddrFrom4([4]byte{203, 0, 113, 5}); addr != want {
+		t.Errorf("got %v, want %v (expected XOR-MAPPED-ADDRESS to win over MAPPED-ADDRESS)", addr, want)
+	}
+}
+
+func TestParseSTUNResponseFallsBackToLegacyMapped(t *testing.T) {
+	var transactionID [12]byte
+	legacyAttr := buildSTUNAttr(stunAttrMappedAddr, []byte{0, stunIPv4Family, 0, 0, 198, 51, 100, 7})
+	response := buildSTUNResponse(transactionID, legacyAttr)
+
+	addr, err := parseSTUNResponse(response, transactionID)
+	if err != nil {
+		t.Fatalf("parseSTUNResponse: %v", err)
+	}
+	if want := netip.AddrFrom4([4]byte{198, 51, 100, 7}); addr != want {
+		t.Errorf("got %v, want %v", addr, want)
+	}
+}
+
+func TestParseSTUNResponseNoMappedAddress(t *testing.T) {
+	var transactionID [12]byte
+	response := buildSTUNResponse(transactionID, nil)
+
+	if _, err := parseSTUNResponse(response, transactionID); err == nil {
+		t.Error("expected an error when the response has no mapped address attribute")
+	}
+}
+
+func TestParseSTUNResponseTooShort(t *testing.T) {
+	if _, err := parseSTUNResponse([]byte{0, 1, 2}, [12]byte{}); err == nil {
+		t.Error("expected an error for a response shorter than the STUN header")
+	}
+}
+
+func TestParseSTUNResponseTruncated(t *testing.T) {
+	var transactionID [12]byte
+	response := buildSTUNResponse(transactionID, buildSTUNAttr(stunAttrMappedAddr, []byte{0, stunIPv4Family, 0, 0, 1, 2, 3, 4}))
+	// Claim a longer message length than the buffer actually holds.
+	binary.BigEndian.PutUint16(response[2:4], 0xFFFF)
+
+	if _, err := parseSTUNResponse(response, transactionID); err == nil {
+		t.Error("expected an error for a response whose declared length exceeds the buffer")
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+const (
+	ssdpMulticastAddr = "239.255.255.250:1900"
+	ssdpSearchTarget  = "urn:schemas-upnp-org:service:WANIPConnection:1"
+)
+
+// -- upnp: ask the local router's IGD for its external IP ------------------
+
+type upnpIPSource struct{}
+
+func newUPnPIPSource(cfg IPSourceConfig) (IPSource, error) {
+	return &upnpIPSource{}, nil
+}
+
+func (s *upnpIPSource) Name() string { return "upnp" }
+
+func (s *upnpIPSource) Fetch(ctx context.Context) (netip.Addr, error) {
+	location, err := ssdpDiscover(ctx)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("upnp: %v", err)
+	}
+
+	controlURL, err := upnpControlURL(ctx, location)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("upnp: %v", err)
+	}
+
+	ip, err := upnpGetExternalIPAddress(ctx, controlURL)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("upnp: %v", err)
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("upnp: invalid external ip %q: %v", ip, err)
+	}
+	return addr, nil
+}
+
+// ssdpDiscover sends an SSDP M-SEARCH for a WANIPConnection device and
+// returns the LOCATION URL of the first device that responds.
+func ssdpDiscover(ctx context.Context) (string, error) {
+	searchMsg := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpMulticastAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: " + ssdpSearchTarget + "\r\n\r\n"
+
+	raddr, err := net.ResolveUDPAddr("udp4", ssdpMulticastAddr)
+	if err != nil {
+		return "", fmt.Errorf("ssdp: resolve multicast address: %v", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", fmt.Errorf("ssdp: listen: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(3 * time.Second)
+	if d, ok := ctx.Deadline(); ok {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.WriteTo([]byte(searchMsg), raddr); err != nil {
+		return "", fmt.Errorf("ssdp: send search: %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		return "", fmt.Errorf("ssdp: no response from any device: %v", err)
+	}
+
+	for _, line := range strings.Split(string(buf[:n]), "\r\n") {
+		if strings.HasPrefix(strings.ToUpper(line), "LOCATION:") {
+			return strings.TrimSpace(line[len("LOCATION:"):]), nil
+		}
+	}
+	return "", fmt.Errorf("ssdp: response had no LOCATION header")
+}
+
+// upnpDevice is the subset of a UPnP device description document needed
+// to find the WANIPConnection service's control URL.
+type upnpDevice struct {
+	Device struct {
+		DeviceList struct {
+			Device []upnpSubDevice `xml:"device"`
+		} `xml:"deviceList"`
+	} `xml:"device"`
+}
+
+type upnpSubDevice struct {
+	DeviceList struct {
+		Device []upnpSubDevice `xml:"device"`
+	} `xml:"deviceList"`
+	ServiceList struct {
+		Service []struct {
+			ServiceType string `xml:"serviceType"`
+			ControlURL  string `xml:"controlURL"`
+		} `xml:"service"`
+	} `xml:"serviceList"`
+}
+
+// upnpControlURL fetches the device description at location and returns
+// the control URL for the WANIPConnection service, resolved against
+// location's host.
+func upnpControlURL(ctx context.Context, location string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return "", fmt.Errorf("fetch device description: %v", err)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("fetch device description: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("read device description: %v", err)
+	}
+
+	var desc upnpDevice
+	if err := xml.Unmarshal(body, &desc); err != nil {
+		return "", fmt.Errorf("parse device description: %v", err)
+	}
+
+	relative, ok := findWANIPConnectionControlURL(desc.Device.DeviceList.Device)
+	if !ok {
+		return "", fmt.Errorf("no WANIPConnection service found in device description")
+	}
+
+	if strings.HasPrefix(relative, "http://") || strings.HasPrefix(relative, "https://") {
+		return relative, nil
+	}
+
+	schemeEnd := strings.Index(location, "://") + 3
+	hostEnd := strings.Index(location[schemeEnd:], "/")
+	var origin string
+	if hostEnd == -1 {
+		origin = location
+	} else {
+		origin = location[:schemeEnd+hostEnd]
+	}
+	if !strings.HasPrefix(relative, "/") {
+		relative = "/" + relative
+	}
+	return origin + relative, nil
+}
+
+// findWANIPConnectionControlURL walks the device list (and nested
+// sub-devices) looking for a WANIPConnection service.
+func findWANIPConnectionControlURL(devices []upnpSubDevice) (string, bool) {
+	for _, d := range devices {
+		for _, svc := range d.ServiceList.Service {
+			if strings.Contains(svc.ServiceType, "WANIPConnection") {
+				return svc.ControlURL, true
+			}
+		}
+		if url, ok := findWANIPConnectionControlURL(d.DeviceList.Device); ok {
+			return url, true
+		}
+	}
+	return "", false
+}
+
+type upnpGetExternalIPResponse struct {
+	Body struct {
+		GetExternalIPAddressResponse struct {
+			NewExternalIPAddress string `xml:"NewExternalIPAddress"`
+		} `xml:"GetExternalIPAddressResponse"`
+	} `xml:"Body"`
+}
+
+// upnpGetExternalIPAddress issues the GetExternalIPAddress SOAP action
+// against the device's control URL and returns the reported address.
+func upnpGetExternalIPAddress(ctx context.Context, controlURL string) (string, error) {
+	soapBody := `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:GetExternalIPAddress xmlns:u="` + ssdpSearchTarget + `"/>
+  </s:Body>
+</s:Envelope>`
+
+	request, err := http.NewRequestWithContext(ctx, "POST", controlURL, strings.NewReader(soapBody))
+	if err != nil {
+		return "", fmt.Errorf("build soap request: %v", err)
+	}
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	request.Header.Set("SOAPAction", `"`+ssdpSearchTarget+`#GetExternalIPAddress"`)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("soap request: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("read soap response: %v", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("soap request failed with status code %v", response.StatusCode)
+	}
+
+	var parsed upnpGetExternalIPResponse
+	if err := xml.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("parse soap response: %v", err)
+	}
+
+	ip := parsed.Body.GetExternalIPAddressResponse.NewExternalIPAddress
+	if ip == "" {
+		return "", fmt.Errorf("soap response had no external ip address")
+	}
+	return ip, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+func TestFindWANIPConnectionControlURLDirectMatch(t *testing.T) {
+	devices := []upnpSubDevice{
+		{
+			ServiceList: struct {
+				Service []struct {
+					ServiceType string `xml:"serviceType"`
+					ControlURL  string `xml:"controlURL"`
+				} `xml:"service"`
+			}{
+				Service: []struct {
+					ServiceType string `xml:"serviceType"`
+					ControlURL  string `xml:"controlURL"`
+				}{
+					{ServiceType: "urn:schemas-upnp-org:service:WANIPConnection:1", ControlURL: "/ctl/IPConn"},
+				},
+			},
+		},
+	}
+
+	url, ok := findWANIPConnectionControlURL(devices)
+	if !ok {
+		t.Fatal("expected to find a WANIPConnection service")
+	}
+	if url != "/ctl/IPConn" {
+		t.Errorf("got control url %q, want %q", url, "/ctl/IPConn")
+	}
+}
+
+func TestFindWANIPConnectionControlURLNested(t *testing.T) {
+	leaf := upnpSubDevice{
+		ServiceList: struct {
+			Service []struct {
+				ServiceType string `xml:"serviceType"`
+				ControlURL  string `xml:"controlURL"`
+			} `xml:"service"`
+		}{
+			Service: []struct {
+				ServiceType string `xml:"serviceType"`
+				ControlURL  string `xml:"controlURL"`
+			}{
+				{ServiceType: "urn:schemas-upnp-org:service:WANCommonInterfaceConfig:1", ControlURL: "/ctl/CommonIfc"},
+			},
+		},
+	}
+	wanConnectionDevice := upnpSubDevice{
+		ServiceList: struct {
+			Service []struct {
+				ServiceType string `xml:"serviceType"`
+				ControlURL  string `xml:"controlURL"`
+			} `xml:"service"`
+		}{
+			Service: []struct {
+				ServiceType string `xml:"serviceType"`
+				ControlURL  string `xml:"controlURL"`
+			}{
+				{ServiceType: "urn:schemas-upnp-org:service:WANIPConnection:1", ControlURL: "/ctl/IPConn"},
+			},
+		},
+	}
+
+	// Mirrors a typical IGD tree: WANDevice -> WANConnectionDevice, where
+	// only the innermost sub-device actually exposes WANIPConnection.
+	wanDevice := upnpSubDevice{}
+	wanDevice.DeviceList.Device = []upnpSubDevice{leaf, wanConnectionDevice}
+
+	url, ok := findWANIPConnectionControlURL([]upnpSubDevice{wanDevice})
+	if !ok {
+		t.Fatal("expected to find a nested WANIPConnection service")
+	}
+	if url != "/ctl/IPConn" {
+		t.Errorf("got control url %q, want %q", url, "/ctl/IPConn")
+	}
+}
+
+func TestFindWANIPConnectionControlURLNotFound(t *testing.T) {
+	devices := []upnpSubDevice{
+		{
+			ServiceList: struct {
+				Service []struct {
+					ServiceType string `xml:"serviceType"`
+					ControlURL  string `xml:"controlURL"`
+				} `xml:"service"`
+			}{
+				Service: []struct {
+					ServiceType string `xml:"serviceType"`
+					ControlURL  string `xml:"controlURL"`
+				}{
+					{ServiceType: "urn:schemas-upnp-org:service:Layer3Forwarding:1", ControlURL: "/ctl/L3F"},
+				},
+			},
+		},
+	}
+
+	if _, ok := findWANIPConnectionControlURL(devices); ok {
+		t.Error("expected no WANIPConnection service to be found")
+	}
+}
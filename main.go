@@ -1,161 +1,285 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"log/slog"
 	"os"
-	"encoding/json"
-	"errors"
-	"net/http"
+	"os/signal"
 	"strings"
-	"regexp"
-	"flag"
+	"syscall"
 	"time"
 )
 
-type Config struct {
-	CheckIPUrl string `json:"url_check_ip"`
-	UpdateIPUrl string `json:"url_update_ip"`
-	Username string `json:"user"`
-	Password string `json:"pass"`
-	Hostname string `json:"hostname"`
-}
+const (
+	defaultInterval      = 5 * time.Minute
+	defaultForceInterval = 24 * time.Hour
+)
 
-// Report error and crash
+// reportErr logs a fatal error and crashes.
 func reportErr(e error) {
-	fmt.Fprintln(os.Stderr, "Error: ", e)
+	slog.Error(e.Error())
 	os.Exit(1)
 }
 
+func main() {
+	defaultConfigFile := "./config.json"
+	configPath := flag.String("c", defaultConfigFile, "A configuration file in json format")
+	daemon := flag.Bool("daemon", false, "Run continuously, polling for IP changes instead of exiting after one update")
+	statePath := flag.String("state", "./state.json", "Path to the daemon state file")
+	logLevel := flag.String("log-level", "info", "Minimum log level: debug, info, warn, or error")
+	logFormat := flag.String("log-format", "text", "Log output format: text or json")
+	flag.Parse()
 
-// Given a path to a config file in json,
-// try to parse it into Config type with
-// corresponding required values
-func getConfig(fpath string) (Config, error) {
-
-	var config Config
+	if err := configureLogging(*logLevel, *logFormat); err != nil {
+		reportErr(err)
+	}
 
-	if _, err := os.Stat(fpath); err != nil {
-		return config, err
+	// check if we have a configPath
+	configFile := strings.TrimSpace(*configPath)
+	if configFile == defaultConfigFile {
+		slog.Info("no config path given, using default", "path", defaultConfigFile)
 	}
 
-	fconfig, err := os.Open(fpath)
+	// read configuration
+	config, err := getConfig(configFile)
 	if err != nil {
-		return config, err
+		reportErr(err)
 	}
 
-	parser := json.NewDecoder(fconfig)
-	if err = parser.Decode(&config); err != nil {
-		return config, err
+	if *daemon {
+		if err := runDaemon(config, *statePath); err != nil {
+			reportErr(err)
+		}
+		return
 	}
 
-	return config, nil
+	// fetch current ip(s), per the configured ip_version / ip_source
+	currentIPs, err := fetchIPs(context.Background(), config)
+	if err != nil {
+		reportErr(err)
+	}
+
+	if failures := updateProviders(context.Background(), config, currentIPs); len(failures) > 0 {
+		reportErr(fmt.Errorf("%d provider(s) failed:\n%s", len(failures), strings.Join(failures, "\n")))
+	}
 }
 
+// configureLogging builds an slog handler from the -log-level/-log-format
+// flags and installs it as the default logger.
+func configureLogging(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("configureLogging: invalid log level %q: %v", level, err)
+	}
 
-// Fetch current IP from specific host
-func fetchIP(c Config) (string, error) {
-	var ip string
-	var err error
-	var response *http.Response
+	opts := &slog.HandlerOptions{Level: lvl}
 
-	if response, err = http.Get(c.CheckIPUrl); err != nil {
-		return ip, err
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("configureLogging: invalid log format %q, must be text or json", format)
 	}
 
-	// close resposne body
-	defer response.Body.Close()
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
 
-	// read content
-	if body, err := ioutil.ReadAll(response.Body); err != nil {
-		return ip, fmt.Errorf("fetchIP: Body parsing error %v", err.Error())
-	} else {
-		ip = string(body)
-	}
+// updateProviders pushes currentIPs to every configured provider,
+// collecting per-provider errors without aborting the whole run, and
+// returns a textual failure per provider that didn't succeed.
+func updateProviders(ctx context.Context, config AppConfig, currentIPs map[string]string) []string {
+	var failures []string
+	for _, pcfg := range config.Providers {
+		provider, err := NewProvider(pcfg)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
 
-	// validate and check ip for proper formatting
-	ip = strings.TrimSpace(string(ip))
-	if ip == "" {
-		return ip, errors.New("fetchIP: Request did not return proper IP\n")
-	}
-	// currently, only expect IPv4
-	if valid, _ := regexp.MatchString("^(\\d{1,3}\\.?){3}\\d{1,3}$", ip); !valid {
-		return ip, errors.New(fmt.Sprintf("fetchIP: Invalid or unknown IP format: %s\n", ip))
-	}
+		supported := filterSupportedIPs(provider, currentIPs)
+		if len(supported) == 0 {
+			slog.Warn("skipping provider, none of its supported record types are configured",
+				"provider", provider.Name(), "supports", provider.SupportedRecordTypes())
+			continue
+		}
 
-	return ip, nil
+		if err := provider.Update(ctx, supported); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+			continue
+		}
 
+		slog.Info("ip updated successfully", "provider", provider.Name())
+	}
+	return failures
 }
 
-func updateIP(c Config, currentIP string) error {
-	var request *http.Request
-	var response *http.Response
-	var err error
+// runDaemon polls for IP changes on config.Interval, pushing an update
+// only when the IP actually changed or config.ForceInterval has elapsed
+// since the last update, and persists progress to statePath so restarts
+// pick up where they left off. It returns when it receives SIGINT/SIGTERM.
+func runDaemon(config AppConfig, statePath string) error {
+	interval := defaultInterval
+	if config.Interval != "" {
+		parsed, err := time.ParseDuration(config.Interval)
+		if err != nil {
+			return fmt.Errorf("runDaemon: invalid interval %q: %v", config.Interval, err)
+		}
+		interval = parsed
+	}
 
-	if request, err = http.NewRequest("POST", c.UpdateIPUrl, nil); err != nil {
-		return fmt.Errorf("updateIP: Failed to generate request with error %v", err.Error())
+	forceInterval := defaultForceInterval
+	if config.ForceInterval != "" {
+		parsed, err := time.ParseDuration(config.ForceInterval)
+		if err != nil {
+			return fmt.Errorf("runDaemon: invalid force_interval %q: %v", config.ForceInterval, err)
+		}
+		forceInterval = parsed
 	}
 
-	query := request.URL.Query()
-	// add credentials
-	query.Add("hostname", c.Hostname)
-	query.Add("myip", currentIP)
-	query.Add("user", c.Username)
-	query.Add("pass", c.Password)
-	// append query string
-	request.URL.RawQuery = query.Encode()
+	state, err := loadState(statePath)
+	if err != nil {
+		return fmt.Errorf("runDaemon: %v", err)
+	}
 
-	// prepare client with timeout
-	timeout := time.Duration(10 * time.Second)
-	client := http.Client{ Timeout: timeout }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// execute
-	if response, err = client.Do(request); err != nil {
-		return fmt.Errorf("updateIP: Failed to update IP with request error %v", err.Error())
-	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		<-sigs
+		slog.Info("received shutdown signal, stopping daemon")
+		cancel()
+	}()
 
-	body, err := ioutil.ReadAll(response.Body)
+	metrics := NewMetrics()
+	shutdownServer, err := startServer(ctx, config.ListenAddr, metrics, statePath)
 	if err != nil {
-		return fmt.Errorf("updateIP: Failed parsing body with error %v", err.Error())
+		return fmt.Errorf("runDaemon: %v", err)
+	}
+	if shutdownServer != nil {
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdownServer(shutdownCtx)
+		}()
 	}
 
-	defer response.Body.Close()
-	
-	if response.StatusCode > 204 {
-		return fmt.Errorf("updateIP: Updated failed status code %v body %v", response.StatusCode, string(body))
+	tick := func() {
+		if err := daemonTick(ctx, config, statePath, &state, forceInterval, metrics); err != nil {
+			slog.Error(err.Error())
+		}
 	}
 
-	return nil
+	tick()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			tick()
+		}
+	}
 }
 
-func main() {
-	defaultConfigFile := "./config.json"
-	configPath := flag.String("c", defaultConfigFile, "A configuration file in json format")
-	flag.Parse()
+// daemonTick performs a single fetch+update cycle, skipping the update
+// if the IP hasn't changed and the force interval hasn't elapsed yet.
+func daemonTick(ctx context.Context, config AppConfig, statePath string, state *State, forceInterval time.Duration, metrics *Metrics) error {
+	fetchStart := time.Now()
+	currentIPs, err := fetchIPs(ctx, config)
+	metrics.RecordFetch(time.Since(fetchStart), currentIPs)
 
-	// check if we have a configPath
-	configFile := strings.TrimSpace(*configPath)
-	if configFile == defaultConfigFile {
-		fmt.Printf("No config path given, using default: %s\n", defaultConfigFile)
+	if err != nil {
+		return err
 	}
 
-	// placeholder parameters
-	var config Config
-	var err error
-	var currentIP string
-	// read configuration
-	if config, err = getConfig(configFile); err != nil {
-		reportErr(err)
+	previousIPs := state.LastIP
+	changed := !ipsEqual(currentIPs, previousIPs)
+	forceDue := state.LastUpdateTime.IsZero() || time.Since(state.LastUpdateTime) >= forceInterval
+	if !changed && !forceDue {
+		slog.Info("ip unchanged, skipping update")
+		return nil
 	}
 
-	// fetch current ip
-	if currentIP, err = fetchIP(config); err != nil {
-		reportErr(err)
+	responses := make(map[string]string)
+	anySuccess := false
+	for i, pcfg := range config.Providers {
+		responseKey := providerResponseKey(pcfg, i)
+
+		provider, err := NewProvider(pcfg)
+		if err != nil {
+			responses[responseKey] = err.Error()
+			metrics.RecordUpdate(pcfg.Type, "failure")
+			continue
+		}
+
+		supported := filterSupportedIPs(provider, currentIPs)
+		if len(supported) == 0 {
+			slog.Warn("skipping provider, none of its supported record types are configured",
+				"provider", provider.Name(), "supports", provider.SupportedRecordTypes())
+			responses[responseKey] = "skipped: provider does not support any configured record type"
+			metrics.RecordUpdate(provider.Name(), "skipped")
+			continue
+		}
+
+		if err := provider.Update(ctx, supported); err != nil {
+			responses[responseKey] = err.Error()
+			slog.Error("provider update failed", "provider", provider.Name(), "error", err)
+			metrics.RecordUpdate(provider.Name(), "failure")
+			// Notify on every failure, not just when the IP changed: a
+			// provider that's been failing every tick since the last
+			// successful update is exactly the case force_interval exists
+			// to catch, and it deserves a page regardless of whether this
+			// particular tick also happened to see a new IP.
+			notifyAll(ctx, config.Webhooks, NotifyEvent{
+				Event:     "update_failed",
+				Provider:  provider.Name(),
+				OldIP:     primaryIP(previousIPs),
+				NewIP:     primaryIP(currentIPs),
+				Error:     err.Error(),
+				Timestamp: time.Now(),
+			})
+			continue
+		}
+
+		responses[responseKey] = "ok"
+		slog.Info("ip updated successfully", "provider", provider.Name())
+		metrics.RecordUpdate(provider.Name(), "success")
+		anySuccess = true
+		if changed {
+			notifyAll(ctx, config.Webhooks, NotifyEvent{
+				Event:     "ip_changed",
+				Provider:  provider.Name(),
+				OldIP:     primaryIP(previousIPs),
+				NewIP:     primaryIP(currentIPs),
+				Timestamp: time.Now(),
+			})
+		}
 	}
 
-	// update ip with provider
-	if err = updateIP(config, currentIP); err != nil {
-		reportErr(err)
+	// Only fold this cycle into "last known good" state if at least one
+	// provider actually got the update. Otherwise a stuck/misconfigured
+	// provider would get marked up-to-date here, and every following
+	// tick would see changed == false and forceDue == false and return
+	// before the provider loop even runs again - no retry for up to
+	// force_interval.
+	state.ProviderResponses = responses
+	if anySuccess {
+		state.LastIP = currentIPs
+		state.LastUpdateTime = time.Now()
+		metrics.RecordUpdateTimestamp(state.LastUpdateTime)
 	}
-	fmt.Println("IP Updated Successfully")
-}
\ No newline at end of file
+
+	return saveState(statePath, *state)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics holds the in-process counters/gauges exposed at /metrics in
+// daemon mode. All fields are protected by mu since updates happen from
+// the ticker goroutine while reads happen from HTTP handler goroutines.
+type Metrics struct {
+	mu sync.Mutex
+
+	updatesTotal        map[[2]string]int // [provider, result] -> count
+	lastUpdateTimestamp float64
+	currentIPs          map[string]string // record type (A/AAAA) -> ip
+	fetchDurationSecs   float64
+}
+
+// NewMetrics returns an empty Metrics ready to record updates.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		updatesTotal: make(map[[2]string]int),
+		currentIPs:   make(map[string]string),
+	}
+}
+
+// RecordUpdate increments the updates counter for a provider/result pair,
+// where result is typically "success" or "failure".
+func (m *Metrics) RecordUpdate(provider, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updatesTotal[[2]string{provider, result}]++
+}
+
+// RecordFetch records how long the most recent IP fetch took, and the
+// current IP(s) once the fetch has succeeded, keyed by record type so an
+// ipv6-only (or dual-stack) config doesn't lose an address family to the
+// gauge.
+func (m *Metrics) RecordFetch(duration time.Duration, ips map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fetchDurationSecs = duration.Seconds()
+	for recordType, ip := range ips {
+		if ip != "" {
+			m.currentIPs[recordType] = ip
+		}
+	}
+}
+
+// RecordUpdateTimestamp records the unix time of the most recent
+// successful update cycle.
+func (m *Metrics) RecordUpdateTimestamp(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastUpdateTimestamp = float64(t.Unix())
+}
+
+// Render produces a Prometheus text-exposition-format snapshot of the
+// current metrics.
+func (m *Metrics) Render() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP ddns_updates_total Total number of provider update attempts.\n")
+	b.WriteString("# TYPE ddns_updates_total counter\n")
+	for key, count := range m.updatesTotal {
+		fmt.Fprintf(&b, "ddns_updates_total{provider=%q,result=%q} %d\n", key[0], key[1], count)
+	}
+
+	b.WriteString("# HELP ddns_last_update_timestamp Unix timestamp of the last update cycle.\n")
+	b.WriteString("# TYPE ddns_last_update_timestamp gauge\n")
+	fmt.Fprintf(&b, "ddns_last_update_timestamp %v\n", m.lastUpdateTimestamp)
+
+	b.WriteString("# HELP ddns_current_ip_info The currently detected public IP, one series per record type.\n")
+	b.WriteString("# TYPE ddns_current_ip_info gauge\n")
+	recordTypes := make([]string, 0, len(m.currentIPs))
+	for recordType := range m.currentIPs {
+		recordTypes = append(recordTypes, recordType)
+	}
+	sort.Strings(recordTypes)
+	for _, recordType := range recordTypes {
+		fmt.Fprintf(&b, "ddns_current_ip_info{ip=%q,record_type=%q} 1\n", m.currentIPs[recordType], recordType)
+	}
+
+	b.WriteString("# HELP ddns_fetch_duration_seconds Duration of the most recent IP fetch.\n")
+	b.WriteString("# TYPE ddns_fetch_duration_seconds gauge\n")
+	fmt.Fprintf(&b, "ddns_fetch_duration_seconds %v\n", m.fetchDurationSecs)
+
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// NotifyEvent describes an IP change or a failed update, sent to every
+// configured webhook so users can wire up Discord/Slack/email without a
+// bespoke integration per DNS provider.
+type NotifyEvent struct {
+	Event     string    `json:"event"` // "ip_changed" or "update_failed"
+	Provider  string    `json:"provider"`
+	OldIP     string    `json:"old_ip"`
+	NewIP     string    `json:"new_ip"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// notifyAll sends event to every configured webhook URL, logging (but
+// not failing the run on) delivery errors.
+func notifyAll(ctx context.Context, webhooks []string, event NotifyEvent) {
+	for _, rawURL := range webhooks {
+		if err := notify(ctx, rawURL, event); err != nil {
+			slog.Error("webhook delivery failed", "url", rawURL, "error", err)
+		}
+	}
+}
+
+// notify delivers event to a single webhook URL. The scheme selects the
+// target service, Shoutrrr-style:
+//
+//	generic://host/path(?...)   - POST the raw NotifyEvent JSON
+//	discord://token@channelID   - Discord incoming webhook
+//	slack://token-a/token-b/token-c - Slack incoming webhook
+//
+// Any other scheme (http/https) is treated as a generic JSON POST target.
+func notify(ctx context.Context, rawURL string, event NotifyEvent) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("notify: invalid webhook url: %v", err)
+	}
+
+	switch parsed.Scheme {
+	case "discord":
+		return notifyDiscord(ctx, parsed, event)
+	case "slack":
+		return notifySlack(ctx, parsed, event)
+	case "generic":
+		target := "https://" + parsed.Host + parsed.Path
+		if parsed.RawQuery != "" {
+			target += "?" + parsed.RawQuery
+		}
+		return postJSON(ctx, target, event)
+	default:
+		return postJSON(ctx, rawURL, event)
+	}
+}
+
+// notifyDiscord maps discord://token@channelID into Discord's standard
+// webhook URL and posts the event as a message content string.
+func notifyDiscord(ctx context.Context, u *url.URL, event NotifyEvent) error {
+	token := u.User.Username()
+	channelID := u.Host
+	if token == "" || channelID == "" {
+		return fmt.Errorf("notify: discord webhook url must be discord://token@channelID")
+	}
+
+	target := fmt.Sprintf("https://discord.com/api/webhooks/%s/%s", channelID, token)
+	body := map[string]string{"content": formatNotifyMessage(event)}
+	return postJSON(ctx, target, body)
+}
+
+// notifySlack maps slack://a/b/c into Slack's incoming webhook URL and
+// posts the event as a plain-text message.
+func notifySlack(ctx context.Context, u *url.URL, event NotifyEvent) error {
+	parts := strings.Trim(u.Path, "/")
+	if u.Host == "" || parts == "" {
+		return fmt.Errorf("notify: slack webhook url must be slack://token-a/token-b/token-c")
+	}
+
+	target := fmt.Sprintf("https://hooks.slack.com/services/%s/%s", u.Host, parts)
+	body := map[string]string{"text": formatNotifyMessage(event)}
+	return postJSON(ctx, target, body)
+}
+
+func formatNotifyMessage(event NotifyEvent) string {
+	if event.Error != "" {
+		return fmt.Sprintf("ddns-updater: %s update failed for %s: %s", event.Provider, event.NewIP, event.Error)
+	}
+	return fmt.Sprintf("ddns-updater: %s updated %s -> %s", event.Provider, event.OldIP, event.NewIP)
+}
+
+// postJSON POSTs v as a JSON body to target.
+func postJSON(ctx context.Context, target string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("notify: failed to encode payload: %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("notify: failed to build request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("notify: request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("notify: webhook returned status code %v", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Provider is implemented by every supported DNS update backend. Update
+// pushes ips (keyed by RecordTypeA / RecordTypeAAAA) to the provider for
+// the hostname it was configured with, for whichever of those record
+// types it supports; Name returns a short identifier used for logging.
+type Provider interface {
+	Name() string
+	SupportedRecordTypes() []string
+	Update(ctx context.Context, ips map[string]string) error
+}
+
+// providerFactories maps the config "provider" discriminator to a
+// constructor for that backend. New backends register themselves here.
+var providerFactories = map[string]func(ProviderConfig) (Provider, error){
+	"dyndns":         newDynDNSProvider,
+	"cloudflare":     newCloudflareProvider,
+	"google-domains": newGoogleDomainsProvider,
+	"duckdns":        newDuckDNSProvider,
+	"namecheap":      newNamecheapProvider,
+	"he.net":         newHeNetProvider,
+	"gandi":          newGandiProvider,
+	"desec":          newDeSECProvider,
+}
+
+// NewProvider builds the concrete Provider for a single config entry
+// based on its Type (the "provider" discriminator field). The hostname
+// is normalized to ASCII and validated as a DNS name first, so a
+// misconfigured entry fails fast instead of silently sending garbage to
+// the provider's API.
+func NewProvider(cfg ProviderConfig) (Provider, error) {
+	factory, ok := providerFactories[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("NewProvider: unknown provider type %q", cfg.Type)
+	}
+
+	if cfg.Hostname != "" {
+		hostname, err := validateHostname(cfg.Hostname)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Hostname = hostname
+	}
+
+	return factory(cfg)
+}
+
+// providerResponseKey returns the key a single provider config entry
+// should be recorded under in State.ProviderResponses. provider.Name()
+// alone isn't enough here: it's fixed per provider type, so two entries
+// of the same type (e.g. two duckdns hostnames) would overwrite each
+// other's response. Hostname uniquely identifies an entry in practice;
+// fall back to the config index for the rare entry without one.
+func providerResponseKey(cfg ProviderConfig, index int) string {
+	if cfg.Hostname != "" {
+		return cfg.Hostname
+	}
+	return fmt.Sprintf("%s#%d", cfg.Type, index)
+}
+
+// filterSupportedIPs narrows ips down to the record types provider
+// actually supports (per SupportedRecordTypes), so a provider that can
+// only serve A records isn't handed an AAAA address it would have to
+// silently ignore, and so the caller can tell "nothing to send" apart
+// from "sent successfully".
+func filterSupportedIPs(provider Provider, ips map[string]string) map[string]string {
+	supported := make(map[string]string, len(ips))
+	for _, recordType := range provider.SupportedRecordTypes() {
+		if ip, ok := ips[recordType]; ok {
+			supported[recordType] = ip
+		}
+	}
+	return supported
+}
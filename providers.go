@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpClient is shared by all providers that talk to an HTTP API.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// -- DynDNS v2 (and the legacy generic dyndns-style endpoint) --------------
+
+type dynDNSProvider struct {
+	hostname    string
+	username    string
+	password    string
+	updateIPUrl string
+}
+
+func newDynDNSProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.Hostname == "" {
+		return nil, fmt.Errorf("dyndns: hostname is required")
+	}
+	updateURL := cfg.UpdateIPUrl
+	if updateURL == "" {
+		updateURL = "https://members.dyndns.org/nic/update"
+	}
+	return &dynDNSProvider{
+		hostname:    cfg.Hostname,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		updateIPUrl: updateURL,
+	}, nil
+}
+
+func (p *dynDNSProvider) Name() string { return "dyndns" }
+
+func (p *dynDNSProvider) SupportedRecordTypes() []string {
+	return []string{RecordTypeA, RecordTypeAAAA}
+}
+
+// Update pushes both the A and AAAA addresses (if present) in a single
+// query, per DynDNS v2's myip/myipv6 parameters.
+func (p *dynDNSProvider) Update(ctx context.Context, ips map[string]string) error {
+	request, err := http.NewRequestWithContext(ctx, "POST", p.updateIPUrl, nil)
+	if err != nil {
+		return fmt.Errorf("dyndns: failed to build request: %v", err)
+	}
+
+	query := request.URL.Query()
+	query.Add("hostname", p.hostname)
+	if ip, ok := ips[RecordTypeA]; ok {
+		query.Add("myip", ip)
+	}
+	if ip, ok := ips[RecordTypeAAAA]; ok {
+		query.Add("myipv6", ip)
+	}
+	request.URL.RawQuery = query.Encode()
+	request.SetBasicAuth(p.username, p.password)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("dyndns: request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode > 204 {
+		return fmt.Errorf("dyndns: update failed with status code %v", response.StatusCode)
+	}
+	return nil
+}
+
+// -- Cloudflare -------------------------------------------------------------
+
+type cloudflareProvider struct {
+	hostname   string
+	zone       string
+	recordID   string
+	recordIDv6 string
+	token      string
+}
+
+func newCloudflareProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.Zone == "" || cfg.RecordID == "" {
+		return nil, fmt.Errorf("cloudflare: zone and record_id are required")
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("cloudflare: token is required")
+	}
+	return &cloudflareProvider{
+		hostname:   cfg.Hostname,
+		zone:       cfg.Zone,
+		recordID:   cfg.RecordID,
+		recordIDv6: cfg.RecordIDv6,
+		token:      cfg.Token,
+	}, nil
+}
+
+func (p *cloudflareProvider) Name() string { return "cloudflare" }
+
+func (p *cloudflareProvider) SupportedRecordTypes() []string {
+	types := []string{RecordTypeA}
+	if p.recordIDv6 != "" {
+		types = append(types, RecordTypeAAAA)
+	}
+	return types
+}
+
+// Update issues one PATCH call per record type, since Cloudflare keeps A
+// and AAAA as separate DNS record resources.
+func (p *cloudflareProvider) Update(ctx context.Context, ips map[string]string) error {
+	if ip, ok := ips[RecordTypeA]; ok {
+		if err := p.updateRecord(ctx, RecordTypeA, p.recordID, ip); err != nil {
+			return err
+		}
+	}
+	if ip, ok := ips[RecordTypeAAAA]; ok && p.recordIDv6 != "" {
+		if err := p.updateRecord(ctx, RecordTypeAAAA, p.recordIDv6, ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *cloudflareProvider) updateRecord(ctx context.Context, recordType, recordID, ip string) error {
+	endpoint := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/dns_records/%s", p.zone, recordID)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type":    recordType,
+		"name":    p.hostname,
+		"content": ip,
+	})
+	if err != nil {
+		return fmt.Errorf("cloudflare: failed to encode request body: %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "PATCH", endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("cloudflare: failed to build request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+p.token)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("cloudflare: request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("cloudflare: %s update failed with status code %v", recordType, response.StatusCode)
+	}
+	return nil
+}
+
+// -- Google Domains ----------------------------------------------------------
+
+type googleDomainsProvider struct {
+	hostname string
+	username string
+	password string
+}
+
+func newGoogleDomainsProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.Hostname == "" || cfg.Username == "" || cfg.Password == "" {
+		return nil, fmt.Errorf("google-domains: hostname, user and pass are required")
+	}
+	return &googleDomainsProvider{hostname: cfg.Hostname, username: cfg.Username, password: cfg.Password}, nil
+}
+
+func (p *googleDomainsProvider) Name() string { return "google-domains" }
+
+func (p *googleDomainsProvider) SupportedRecordTypes() []string {
+	return []string{RecordTypeA}
+}
+
+func (p *googleDomainsProvider) Update(ctx context.Context, ips map[string]string) error {
+	ip, ok := ips[RecordTypeA]
+	if !ok {
+		return nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", "https://domains.google.com/nic/update", nil)
+	if err != nil {
+		return fmt.Errorf("google-domains: failed to build request: %v", err)
+	}
+
+	query := request.URL.Query()
+	query.Add("hostname", p.hostname)
+	query.Add("myip", ip)
+	request.URL.RawQuery = query.Encode()
+	request.SetBasicAuth(p.username, p.password)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("google-domains: request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode > 204 {
+		return fmt.Errorf("google-domains: update failed with status code %v", response.StatusCode)
+	}
+	return nil
+}
+
+// -- DuckDNS ------------------------------------------------------------------
+
+type duckDNSProvider struct {
+	hostname string
+	token    string
+}
+
+func newDuckDNSProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.Hostname == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("duckdns: hostname and token are required")
+	}
+	return &duckDNSProvider{hostname: cfg.Hostname, token: cfg.Token}, nil
+}
+
+func (p *duckDNSProvider) Name() string { return "duckdns" }
+
+func (p *duckDNSProvider) SupportedRecordTypes() []string {
+	return []string{RecordTypeA, RecordTypeAAAA}
+}
+
+// Update sends both addresses in a single call, per DuckDNS's ip/ipv6
+// parameters.
+func (p *duckDNSProvider) Update(ctx context.Context, ips map[string]string) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", "https://www.duckdns.org/update", nil)
+	if err != nil {
+		return fmt.Errorf("duckdns: failed to build request: %v", err)
+	}
+
+	query := request.URL.Query()
+	query.Add("domains", p.hostname)
+	query.Add("token", p.token)
+	if ip, ok := ips[RecordTypeA]; ok {
+		query.Add("ip", ip)
+	}
+	if ip, ok := ips[RecordTypeAAAA]; ok {
+		query.Add("ipv6", ip)
+	}
+	request.URL.RawQuery = query.Encode()
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("duckdns: request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("duckdns: update failed with status code %v", response.StatusCode)
+	}
+	return nil
+}
+
+// -- Namecheap ----------------------------------------------------------------
+
+type namecheapProvider struct {
+	hostname string
+	domain   string
+	password string
+}
+
+func newNamecheapProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.Hostname == "" || cfg.Password == "" {
+		return nil, fmt.Errorf("namecheap: hostname and pass are required")
+	}
+	host, domain := splitHostDomain(cfg.Hostname)
+	return &namecheapProvider{hostname: host, domain: domain, password: cfg.Password}, nil
+}
+
+func (p *namecheapProvider) Name() string { return "namecheap" }
+
+func (p *namecheapProvider) SupportedRecordTypes() []string {
+	return []string{RecordTypeA}
+}
+
+func (p *namecheapProvider) Update(ctx context.Context, ips map[string]string) error {
+	ip, ok := ips[RecordTypeA]
+	if !ok {
+		return nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", "https://dynamicdns.park-your-domain.com/update", nil)
+	if err != nil {
+		return fmt.Errorf("namecheap: failed to build request: %v", err)
+	}
+
+	query := request.URL.Query()
+	query.Add("host", p.hostname)
+	query.Add("domain", p.domain)
+	query.Add("password", p.password)
+	query.Add("ip", ip)
+	request.URL.RawQuery = query.Encode()
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("namecheap: request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("namecheap: update failed with status code %v", response.StatusCode)
+	}
+	return nil
+}
+
+// splitHostDomain splits "sub.example.com" into host "sub" and domain
+// "example.com", matching Namecheap's separate host/domain parameters.
+// A bare "example.com" yields host "@" per Namecheap's convention.
+func splitHostDomain(fqdn string) (host, domain string) {
+	parts := strings.SplitN(fqdn, ".", 2)
+	if len(parts) != 2 {
+		return "@", fqdn
+	}
+	return parts[0], parts[1]
+}
+
+// -- He.net (Hurricane Electric) ----------------------------------------------
+
+type heNetProvider struct {
+	hostname string
+	password string
+}
+
+func newHeNetProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.Hostname == "" || cfg.Password == "" {
+		return nil, fmt.Errorf("he.net: hostname and pass are required")
+	}
+	return &heNetProvider{hostname: cfg.Hostname, password: cfg.Password}, nil
+}
+
+func (p *heNetProvider) Name() string { return "he.net" }
+
+func (p *heNetProvider) SupportedRecordTypes() []string {
+	return []string{RecordTypeA, RecordTypeAAAA}
+}
+
+// Update sends both addresses in a single call, per He.net's myip/myipv6
+// parameters.
+func (p *heNetProvider) Update(ctx context.Context, ips map[string]string) error {
+	request, err := http.NewRequestWithContext(ctx, "POST", "https://dyn.dns.he.net/nic/update", nil)
+	if err != nil {
+		return fmt.Errorf("he.net: failed to build request: %v", err)
+	}
+
+	query := request.URL.Query()
+	query.Add("hostname", p.hostname)
+	query.Add("password", p.password)
+	if ip, ok := ips[RecordTypeA]; ok {
+		query.Add("myip", ip)
+	}
+	if ip, ok := ips[RecordTypeAAAA]; ok {
+		query.Add("myipv6", ip)
+	}
+	request.URL.RawQuery = query.Encode()
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("he.net: request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("he.net: update failed with status code %v", response.StatusCode)
+	}
+	return nil
+}
+
+// -- Gandi LiveDNS --------------------------------------------------------------
+
+type gandiProvider struct {
+	hostname string
+	zone     string
+	token    string
+}
+
+func newGandiProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.Zone == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("gandi: zone and token are required")
+	}
+	return &gandiProvider{hostname: cfg.Hostname, zone: cfg.Zone, token: cfg.Token}, nil
+}
+
+func (p *gandiProvider) Name() string { return "gandi" }
+
+func (p *gandiProvider) SupportedRecordTypes() []string {
+	return []string{RecordTypeA, RecordTypeAAAA}
+}
+
+// Update issues one PUT call per record type against Gandi's per-type
+// rrset endpoint.
+func (p *gandiProvider) Update(ctx context.Context, ips map[string]string) error {
+	if ip, ok := ips[RecordTypeA]; ok {
+		if err := p.updateRecord(ctx, RecordTypeA, ip); err != nil {
+			return err
+		}
+	}
+	if ip, ok := ips[RecordTypeAAAA]; ok {
+		if err := p.updateRecord(ctx, RecordTypeAAAA, ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *gandiProvider) updateRecord(ctx context.Context, recordType, ip string) error {
+	endpoint := fmt.Sprintf("https://api.gandi.net/v5/livedns/domains/%s/records/%s/%s", p.zone, p.hostname, recordType)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"rrset_values": []string{ip},
+		"rrset_ttl":    300,
+	})
+	if err != nil {
+		return fmt.Errorf("gandi: failed to encode request body: %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "PUT", endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("gandi: failed to build request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+p.token)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("gandi: request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusCreated && response.StatusCode != http.StatusOK {
+		return fmt.Errorf("gandi: %s update failed with status code %v", recordType, response.StatusCode)
+	}
+	return nil
+}
+
+// -- deSEC ------------------------------------------------------------------
+
+type deSECProvider struct {
+	hostname string
+	token    string
+}
+
+func newDeSECProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.Hostname == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("desec: hostname and token are required")
+	}
+	return &deSECProvider{hostname: cfg.Hostname, token: cfg.Token}, nil
+}
+
+func (p *deSECProvider) Name() string { return "desec" }
+
+func (p *deSECProvider) SupportedRecordTypes() []string {
+	return []string{RecordTypeA, RecordTypeAAAA}
+}
+
+// Update sends both addresses in a single call, per deSEC's myip/myipv6
+// parameters.
+func (p *deSECProvider) Update(ctx context.Context, ips map[string]string) error {
+	query := url.Values{}
+	query.Add("hostname", p.hostname)
+	if ip, ok := ips[RecordTypeA]; ok {
+		query.Add("myip", ip)
+	}
+	if ip, ok := ips[RecordTypeAAAA]; ok {
+		query.Add("myipv6", ip)
+	}
+
+	endpoint := "https://update.dedyn.io/?" + query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("desec: failed to build request: %v", err)
+	}
+	request.Header.Set("Authorization", "Token "+p.token)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("desec: request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("desec: update failed with status code %v", response.StatusCode)
+	}
+	return nil
+}
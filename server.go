@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// startServer starts the observability HTTP server used in daemon mode
+// (/healthz, /metrics, /status) and returns a shutdown func. It returns a
+// nil shutdown func if listenAddr is empty, since the server is disabled
+// by default.
+func startServer(ctx context.Context, listenAddr string, metrics *Metrics, statePath string) (func(context.Context) error, error) {
+	if listenAddr == "" {
+		return nil, nil
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(metrics.Render()))
+	})
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		state, err := loadState(statePath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	})
+
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("observability server failed", "error", err)
+		}
+	}()
+	slog.Info("observability server listening", "addr", listenAddr)
+
+	return server.Shutdown, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State is the on-disk record of the last successful run, used by daemon
+// mode to decide whether an update is actually needed.
+type State struct {
+	LastIP            map[string]string `json:"last_ip"`
+	LastUpdateTime    time.Time         `json:"last_update_time"`
+	ProviderResponses map[string]string `json:"provider_responses"`
+}
+
+// loadState reads state from path. A missing file is not an error: it
+// just means this is the first run, so a zero-value State is returned.
+func loadState(path string) (State, error) {
+	var state State
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("loadState: %v", err)
+	}
+	return state, nil
+}
+
+// saveState writes state to path atomically, via a temp file in the same
+// directory followed by a rename, so a crash mid-write can't leave a
+// truncated or corrupt state file behind.
+func saveState(path string, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("saveState: %v", err)
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".state-*.json.tmp")
+	if err != nil {
+		return fmt.Errorf("saveState: %v", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("saveState: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("saveState: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("saveState: %v", err)
+	}
+	return nil
+}
+
+// ipsEqual reports whether two record-type -> IP maps hold the same
+// addresses.
+func ipsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
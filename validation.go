@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+
+	"golang.org/x/net/idna"
+)
+
+// dnsLabelRe matches a single valid DNS label: letters, digits and
+// hyphens, neither starting nor ending with a hyphen.
+var dnsLabelRe = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// validateHostname normalizes hostname to ASCII (punycode-encoding any
+// non-ASCII labels) and checks the result is a well-formed DNS name, so a
+// typo or stray character doesn't silently produce a request to the
+// wrong record.
+func validateHostname(hostname string) (string, error) {
+	if hostname == "" {
+		return "", fmt.Errorf("validateHostname: hostname is required")
+	}
+
+	ascii, err := idna.ToASCII(hostname)
+	if err != nil {
+		return "", fmt.Errorf("validateHostname: %q is not a valid hostname: %v", hostname, err)
+	}
+
+	if len(ascii) > 253 {
+		return "", fmt.Errorf("validateHostname: %q is too long for a DNS name", hostname)
+	}
+
+	for _, label := range splitLabels(ascii) {
+		if !dnsLabelRe.MatchString(label) {
+			return "", fmt.Errorf("validateHostname: %q is not a valid DNS name", hostname)
+		}
+	}
+
+	return ascii, nil
+}
+
+func splitLabels(name string) []string {
+	var labels []string
+	start := 0
+	for i := 0; i < len(name); i++ {
+		if name[i] == '.' {
+			labels = append(labels, name[start:i])
+			start = i + 1
+		}
+	}
+	labels = append(labels, name[start:])
+	return labels
+}
+
+// ipPolicy holds the parsed allow/deny lists used to sanity-check an IP
+// fetched from a check-IP source before it's ever sent to a provider.
+type ipPolicy struct {
+	allowed []netip.Prefix
+	denied  []netip.Prefix
+}
+
+// newIPPolicy parses the allowed_cidrs / denied_cidrs config fields.
+func newIPPolicy(config AppConfig) (ipPolicy, error) {
+	var policy ipPolicy
+
+	for _, cidr := range config.AllowedCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return policy, fmt.Errorf("newIPPolicy: invalid allowed_cidrs entry %q: %v", cidr, err)
+		}
+		policy.allowed = append(policy.allowed, prefix)
+	}
+	for _, cidr := range config.DeniedCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return policy, fmt.Errorf("newIPPolicy: invalid denied_cidrs entry %q: %v", cidr, err)
+		}
+		policy.denied = append(policy.denied, prefix)
+	}
+
+	return policy, nil
+}
+
+// validate rejects ip if it falls in a denied range, or in one of the
+// ranges that are never sane to publish (private, loopback, link-local,
+// multicast, unspecified) unless it's explicitly allow-listed. This
+// keeps a misconfigured echo service that returns HTML (or a NAT'd
+// private address) from silently being pushed to a DNS provider.
+func (p ipPolicy) validate(ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("ipPolicy: %q is not a valid IP address", ip)
+	}
+
+	for _, deny := range p.denied {
+		if deny.Contains(addr) {
+			return fmt.Errorf("ipPolicy: %s is in denied range %s", ip, deny)
+		}
+	}
+
+	for _, allow := range p.allowed {
+		if allow.Contains(addr) {
+			return nil
+		}
+	}
+
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return fmt.Errorf("ipPolicy: %s is not a publishable address, allow-list it via allowed_cidrs if this is intentional", ip)
+	}
+
+	return nil
+}
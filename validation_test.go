@@ -0,0 +1,109 @@
+package main
+
+import "testing"
+
+func TestValidateHostname(t *testing.T) {
+	cases := []struct {
+		name     string
+		hostname string
+		want     string
+		wantErr  bool
+	}{
+		{name: "plain ascii", hostname: "home.example.com", want: "home.example.com"},
+		{name: "idna-normalizes unicode", hostname: "münchen.example.com", want: "xn--mnchen-3ya.example.com"},
+		{name: "empty", hostname: "", wantErr: true},
+		{name: "label with invalid character", hostname: "home_lan.example.com", wantErr: true},
+		{name: "label starting with hyphen", hostname: "-home.example.com", wantErr: true},
+		{name: "too long", hostname: longHostnameForTest(), wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := validateHostname(tc.hostname)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("validateHostname(%q) = %q, nil; want an error", tc.hostname, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateHostname(%q): %v", tc.hostname, err)
+			}
+			if tc.want != "" && got != tc.want {
+				t.Errorf("validateHostname(%q) = %q, want %q", tc.hostname, got, tc.want)
+			}
+		})
+	}
+}
+
+// longHostnameForTest builds a syntactically-plausible hostname longer
+// than the 253-byte DNS name limit.
+func longHostnameForTest() string {
+	label := "abcdefghijklmnopqrstuvwxyz0123456789abcdefghijklmnopqrstuvwxyz"
+	hostname := ""
+	for len(hostname) < 300 {
+		hostname += label + "."
+	}
+	return hostname + "com"
+}
+
+func TestIPPolicyValidate(t *testing.T) {
+	policy, err := newIPPolicy(AppConfig{
+		AllowedCIDRs: []string{"192.168.1.0/24"},
+		DeniedCIDRs:  []string{"203.0.113.0/24"},
+	})
+	if err != nil {
+		t.Fatalf("newIPPolicy: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		ip      string
+		wantErr bool
+	}{
+		{name: "public address passes", ip: "198.51.100.7"},
+		{name: "private address rejected by default", ip: "10.0.0.5", wantErr: true},
+		{name: "private address allow-listed", ip: "192.168.1.42"},
+		{name: "explicitly denied range wins over public-ness", ip: "203.0.113.9", wantErr: true},
+		{name: "loopback rejected", ip: "127.0.0.1", wantErr: true},
+		{name: "unspecified rejected", ip: "0.0.0.0", wantErr: true},
+		{name: "invalid address", ip: "not-an-ip", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.validate(tc.ip)
+			if tc.wantErr && err == nil {
+				t.Errorf("validate(%q) = nil, want an error", tc.ip)
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("validate(%q) = %v, want nil", tc.ip, err)
+			}
+		})
+	}
+}
+
+func TestIPPolicyValidateDeniedOverridesAllowed(t *testing.T) {
+	// A CIDR present in both lists should still be rejected: deny is
+	// checked first and unconditionally wins.
+	policy, err := newIPPolicy(AppConfig{
+		AllowedCIDRs: []string{"203.0.113.0/24"},
+		DeniedCIDRs:  []string{"203.0.113.0/24"},
+	})
+	if err != nil {
+		t.Fatalf("newIPPolicy: %v", err)
+	}
+
+	if err := policy.validate("203.0.113.9"); err == nil {
+		t.Error("expected the denied range to take precedence over the allowed range")
+	}
+}
+
+func TestNewIPPolicyInvalidCIDR(t *testing.T) {
+	if _, err := newIPPolicy(AppConfig{AllowedCIDRs: []string{"not-a-cidr"}}); err == nil {
+		t.Error("expected an error for an invalid allowed_cidrs entry")
+	}
+	if _, err := newIPPolicy(AppConfig{DeniedCIDRs: []string{"not-a-cidr"}}); err == nil {
+		t.Error("expected an error for an invalid denied_cidrs entry")
+	}
+}